@@ -0,0 +1,81 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"os"
+	"reflect"
+)
+
+// Standard BSON binary subtypes, as defined by the BSON spec.
+const (
+	BinaryGeneric    = 0x00
+	BinaryFunction   = 0x01
+	BinaryOld        = 0x02
+	BinaryUUIDOld    = 0x03
+	BinaryUUID       = 0x04
+	BinaryMD5        = 0x05
+	BinaryUserDefine = 0x80
+)
+
+// Binary represents BSON binary data together with its subtype, so that
+// the subtype survives a round trip instead of being discarded in
+// favor of a bare []byte of subtype 0x00.
+type Binary struct {
+	Kind byte
+	Data []byte
+}
+
+func (b Binary) MarshalBSON() (byte, []byte, os.Error) {
+	data := b.Data
+	if b.Kind == BinaryOld {
+		inner := make([]byte, 4+len(data))
+		order.PutUint32(inner, uint32(len(data)))
+		copy(inner[4:], data)
+		data = inner
+	}
+	buf := make([]byte, 5+len(data))
+	order.PutUint32(buf, uint32(len(data)))
+	buf[4] = b.Kind
+	copy(buf[5:], data)
+	return elBinary, buf, nil
+}
+
+// binaryPayload strips the subtype byte, and for the legacy BinaryOld
+// subtype also strips the redundant inner length prefix, returning the
+// subtype and the actual data bytes.
+func binaryPayload(b []byte) (kind byte, data []byte) {
+	kind = b[0]
+	data = b[1:]
+	if kind == BinaryOld && len(data) >= 4 {
+		data = data[4:]
+	}
+	return
+}
+
+// UUID is a convenience type for the common case of a 16-byte BSON
+// binary value with subtype 0x04.
+type UUID [16]byte
+
+func (u UUID) MarshalBSON() (byte, []byte, os.Error) {
+	return Binary{BinaryUUID, u[:]}.MarshalBSON()
+}
+
+var binaryType = reflect.Typeof(Binary{})
+var uuidType = reflect.Typeof(UUID{})
+
+// binarySubtypes holds zero-value constructors registered with
+// RegisterBinarySubtype, keyed by subtype byte.
+var binarySubtypes = make(map[byte]func() interface{})
+
+// RegisterBinarySubtype registers a zero-value constructor for a BSON
+// binary subtype, so that decoding a binary element into an
+// interface{} (for example a map[string]interface{} field) produces a
+// value of that type instead of a bare Binary. The value returned by
+// zero must implement Setter; its SetBSON is called with the element's
+// kind and data, exactly as for a concrete Setter-typed field.
+func RegisterBinarySubtype(subtype byte, zero func() interface{}) {
+	binarySubtypes[subtype] = zero
+}
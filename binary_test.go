@@ -0,0 +1,78 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"os"
+	"testing"
+)
+
+func TestBinarySubtypeRoundTrip(t *testing.T) {
+	want := Binary{BinaryMD5, []byte{1, 2, 3, 4}}
+	b, err := Marshal(map[string]interface{}{"b": want})
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err.String())
+	}
+
+	var doc struct {
+		B Binary
+	}
+	if err := Unmarshal(b, &doc); err != nil {
+		t.Fatalf("Unmarshal error: %s", err.String())
+	}
+	if doc.B.Kind != want.Kind || string(doc.B.Data) != string(want.Data) {
+		t.Errorf("doc.B = %+v, want %+v", doc.B, want)
+	}
+}
+
+type customUUID struct {
+	bytes [16]byte
+}
+
+func (u *customUUID) SetBSON(raw Raw) os.Error {
+	_, data := binaryPayload(raw.Data)
+	copy(u.bytes[:], data)
+	return nil
+}
+
+func TestRegisterBinarySubtype(t *testing.T) {
+	RegisterBinarySubtype(BinaryUUID, func() interface{} { return &customUUID{} })
+
+	want := UUID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	b, err := Marshal(map[string]interface{}{"u": want})
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err.String())
+	}
+
+	var doc map[string]interface{}
+	if err := Unmarshal(b, &doc); err != nil {
+		t.Fatalf("Unmarshal error: %s", err.String())
+	}
+	got, ok := doc["u"].(*customUUID)
+	if !ok {
+		t.Fatalf("doc[\"u\"] has type %T, want *customUUID", doc["u"])
+	}
+	if got.bytes != [16]byte(want) {
+		t.Errorf("got.bytes = %v, want %v", got.bytes, want)
+	}
+}
+
+func TestUUIDRoundTrip(t *testing.T) {
+	want := UUID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	b, err := Marshal(map[string]interface{}{"u": want})
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err.String())
+	}
+
+	var doc struct {
+		U UUID
+	}
+	if err := Unmarshal(b, &doc); err != nil {
+		t.Fatalf("Unmarshal error: %s", err.String())
+	}
+	if doc.U != want {
+		t.Errorf("doc.U = %v, want %v", doc.U, want)
+	}
+}
@@ -63,6 +63,12 @@ var bsonTests = []struct {
 	{&struct{ Test MaxKey }{}, []byte("\x0B\x00\x00\x00\x7FTest\x00\x00")},
 	{map[string]interface{}{"test": MinKey{}}, []byte("\x0B\x00\x00\x00\xFFtest\x00\x00")},
 	{&struct{ Test MinKey }{}, []byte("\x0B\x00\x00\x00\xFFTest\x00\x00")},
+	{map[string]interface{}{"test": Decimal128{0x3040000000000000, 0x0000000000000001}}, []byte("\x1B\x00\x00\x00\x13test\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x40\x30\x00")},
+	{&struct{ Test Decimal128 }{Decimal128{0x3040000000000000, 0x0000000000000001}}, []byte("\x1B\x00\x00\x00\x13Test\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x40\x30\x00")},
+	{&struct {
+		E float64 "e,omitempty"
+	}{0},
+		[]byte("\x05\x00\x00\x00\x00")},
 	{map[string]interface{}{"BSON": []interface{}{"awesome", float64(5.05), int32(1986)}}, []byte("\x31\x00\x00\x00\x04BSON\x00\x26\x00\x00\x00\x02\x30\x00\x08\x00\x00\x00awesome\x00\x01\x31\x00\x33\x33\x33\x33\x33\x33\x14\x40\x10\x32\x00\xC2\x07\x00\x00\x00\x00")},
 	{map[string]interface{}{"BSON": []interface{}{int64(22055360), int64(12688462), int64(212446583), int64(37455565), int64(73465456),
 		int64(17133954), int64(14786502), int64(51854974), int64(71727795),
@@ -105,6 +111,146 @@ func TestUnmarshal(t *testing.T) {
 	}
 }
 
+var decimal128Strings = []string{
+	"0",
+	"1",
+	"-1",
+	"1.5",
+	"0.001",
+	"123456789012345678901234567890123",
+	"5.3E+10",
+	"-5.3E-10",
+	"NaN",
+	"Infinity",
+	"-Infinity",
+}
+
+func TestDecimal128RoundTrip(t *testing.T) {
+	for _, s := range decimal128Strings {
+		d, err := ParseDecimal128(s)
+		if err != nil {
+			t.Errorf("ParseDecimal128(%q) error: %s", s, err.String())
+			continue
+		}
+		if got := d.String(); got != s {
+			t.Errorf("ParseDecimal128(%q).String() = %q, want %q", s, got, s)
+		}
+	}
+}
+
+func TestTagSkip(t *testing.T) {
+	doc := &struct {
+		E float64 "-"
+	}{3.14159}
+	bson, err := Marshal(doc)
+	if err != nil {
+		t.Fatalf("error: %s", err.String())
+	}
+	want := []byte("\x05\x00\x00\x00\x00")
+	if !bytes.Equal(bson, want) {
+		t.Errorf("expected\n%q\ngot\n%q", want, bson)
+	}
+}
+
+func TestTagMinsize(t *testing.T) {
+	doc := &struct {
+		N int64 "n,minsize"
+	}{42}
+	bson, err := Marshal(doc)
+	if err != nil {
+		t.Fatalf("error: %s", err.String())
+	}
+	want := []byte("\x0C\x00\x00\x00\x10n\x00\x2A\x00\x00\x00\x00")
+	if !bytes.Equal(bson, want) {
+		t.Errorf("expected\n%q\ngot\n%q", want, bson)
+	}
+}
+
+func TestTagBSONKeyForm(t *testing.T) {
+	doc := &struct {
+		E float64 `bson:"e,omitempty"`
+	}{0}
+	bson, err := Marshal(doc)
+	if err != nil {
+		t.Fatalf("error: %s", err.String())
+	}
+	want := []byte("\x05\x00\x00\x00\x00")
+	if !bytes.Equal(bson, want) {
+		t.Errorf("expected\n%q\ngot\n%q", want, bson)
+	}
+
+	doc2 := &struct {
+		N int64 `bson:"n,minsize"`
+	}{42}
+	bson, err = Marshal(doc2)
+	if err != nil {
+		t.Fatalf("error: %s", err.String())
+	}
+	want = []byte("\x0C\x00\x00\x00\x10n\x00\x2A\x00\x00\x00\x00")
+	if !bytes.Equal(bson, want) {
+		t.Errorf("expected\n%q\ngot\n%q", want, bson)
+	}
+}
+
+func TestTagInlineStructRoundTrip(t *testing.T) {
+	// Meta is a named (non-anonymous) field, so unlike an anonymous
+	// embedded struct its fields are only reachable through the
+	// "inline" splicing path, not through Go's own field promotion.
+	type Meta struct {
+		X int32
+		Y int32
+	}
+	type Outer struct {
+		Meta Meta `bson:",inline"`
+		Name string
+	}
+	doc := &Outer{Meta{1, 2}, "p"}
+	b, err := Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err.String())
+	}
+
+	var got Outer
+	if err := Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal error: %s", err.String())
+	}
+	if got.Meta.X != 1 || got.Meta.Y != 2 || got.Name != "p" {
+		t.Errorf("Unmarshal got %+v, want {Meta:{1 2} Name:p}", got)
+	}
+}
+
+func TestTagInlineMapRoundTrip(t *testing.T) {
+	doc := &struct {
+		Name  string
+		Extra map[string]interface{} `bson:",inline"`
+	}{"p", map[string]interface{}{"x": int32(1)}}
+	b, err := Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err.String())
+	}
+
+	var got struct {
+		Name  string
+		Extra map[string]interface{} `bson:",inline"`
+	}
+	if err := Unmarshal(b, &got); err != nil {
+		t.Fatalf("Unmarshal error: %s", err.String())
+	}
+	if got.Name != "p" || got.Extra["x"] != int32(1) {
+		t.Errorf("Unmarshal got %+v", got)
+	}
+}
+
+func TestTagInlineCollision(t *testing.T) {
+	doc := &struct {
+		Name  string
+		Extra map[string]interface{} `bson:",inline"`
+	}{"p", map[string]interface{}{"Name": "collides"}}
+	if _, err := Marshal(doc); err == nil {
+		t.Fatal("Marshal with an inline field colliding with \"Name\" succeeded, want error")
+	}
+}
+
 func BenchmarkLargeMapEncode(b *testing.B) {
 	b.StopTimer()
 	media := map[string]interface{} {
@@ -0,0 +1,438 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Command bsongen generates reflection-free MarshalBSON/UnmarshalBSON
+// methods for struct types marked with a "//bson:generate" comment.
+//
+// Given a source file, bsongen writes a sibling "<file>_bson.go" in the
+// same package containing, for each marked struct, a MarshalBSON method
+// matching the existing Marshaler interface and an UnmarshalBSON method
+// matching bson.Unmarshaler, which parses the same wire format back
+// out. Both methods write and read the document's bytes directly
+// instead of going through reflect.Typeof/reflect.MakeZero, which is
+// where the reflect-based encoder and decoder in this package spend
+// most of their time on large structs.
+//
+// Usage:
+//
+//	bsongen input.go
+//
+// Only fields of type string, bool, int32, int64, float64 and []byte are
+// supported; a marked struct with any other field type is reported and
+// skipped. Embedded fields are left out of the generated methods
+// without being reported.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Element kind bytes, as defined by the BSON spec. Generated code writes
+// these directly rather than importing the bson package's unexported
+// constants.
+const (
+	kindFloat  = 0x01
+	kindString = 0x02
+	kindDoc    = 0x03
+	kindBinary = 0x05
+	kindBool   = 0x08
+	kindInt32  = 0x10
+	kindInt64  = 0x12
+)
+
+type field struct {
+	goName    string
+	bsonName  string
+	omitempty bool
+	typ       string // one of "string", "bool", "int32", "int64", "float64", "[]byte"
+}
+
+type genStruct struct {
+	name   string
+	fields []field
+}
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Fprintln(os.Stderr, "usage: bsongen <file.go>")
+		os.Exit(1)
+	}
+	if err := run(os.Args[1]); err != nil {
+		fmt.Fprintln(os.Stderr, "bsongen:", err)
+		os.Exit(1)
+	}
+}
+
+func run(path string) os.Error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path, nil, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+
+	var structs []genStruct
+	ast.Inspect(f, func(n ast.Node) bool {
+		gd, ok := n.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			return true
+		}
+		for _, spec := range gd.Specs {
+			ts, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			if !marked(gd.Doc) && !marked(ts.Doc) {
+				continue
+			}
+			st, ok := ts.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			gs, err := buildStruct(ts.Name.Name, st)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "bsongen: skipping %s: %s\n", ts.Name.Name, err)
+				continue
+			}
+			structs = append(structs, gs)
+		}
+		return true
+	})
+
+	if len(structs) == 0 {
+		return nil
+	}
+
+	needsMath := false
+	for _, gs := range structs {
+		for _, fl := range gs.fields {
+			if fl.typ == "float64" {
+				needsMath = true
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by bsongen from %s. DO NOT EDIT.\n\n", path)
+	fmt.Fprintf(&buf, "package %s\n\n", f.Name.Name)
+	fmt.Fprintf(&buf, "import (\n\t\"bytes\"\n\t\"encoding/binary\"\n")
+	if needsMath {
+		fmt.Fprintf(&buf, "\t\"math\"\n")
+	}
+	fmt.Fprintf(&buf, "\t\"os\"\n)\n\n")
+	writeSkipElem(&buf)
+	for _, gs := range structs {
+		writeMarshal(&buf, gs)
+		writeUnmarshal(&buf, gs)
+	}
+
+	out := strings.TrimSuffix(path, ".go") + "_bson.go"
+	return writeFile(out, buf.Bytes())
+}
+
+func writeFile(path string, data []byte) os.Error {
+	fh, err := os.Open(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	_, err = fh.Write(data)
+	return err
+}
+
+// marked reports whether a doc comment contains a "bson:generate" line.
+func marked(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.Contains(c.Text, "bson:generate") {
+			return true
+		}
+	}
+	return false
+}
+
+func buildStruct(name string, st *ast.StructType) (genStruct, os.Error) {
+	gs := genStruct{name: name}
+	for _, f := range st.Fields.List {
+		if len(f.Names) == 0 {
+			continue // skip embedded fields; not supported
+		}
+		typ, ok := fieldType(f.Type)
+		if !ok {
+			return gs, os.NewError("unsupported field type for " + f.Names[0].Name)
+		}
+		bsonName, omitempty, skip := parseFieldTag(f.Tag)
+		for _, n := range f.Names {
+			if !n.IsExported() || skip {
+				continue
+			}
+			name := bsonName
+			if name == "" {
+				name = n.Name
+			}
+			gs.fields = append(gs.fields, field{n.Name, name, omitempty, typ})
+		}
+	}
+	return gs, nil
+}
+
+func fieldType(expr ast.Expr) (string, bool) {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case "string", "bool", "int32", "int64", "float64":
+			return t.Name, true
+		}
+	case *ast.ArrayType:
+		if t.Len == nil {
+			if ident, ok := t.Elt.(*ast.Ident); ok && ident.Name == "byte" {
+				return "[]byte", true
+			}
+		}
+	}
+	return "", false
+}
+
+// parseFieldTag reads the bson struct tag off of f, in the same
+// "name,opt1,opt2" format accepted by the reflect-based encoder. As in
+// bson.lookupBSONTag, a tag written in the conventional
+// `key:"value" key2:"value2"` form has its "bson" key extracted; a tag
+// that doesn't look like that form at all is used as-is, so the older
+// bare "name,opt1,opt2" tags still work here too.
+func parseFieldTag(tag *ast.BasicLit) (name string, omitempty, skip bool) {
+	if tag == nil {
+		return
+	}
+	unquoted, err := strconv.Unquote(tag.Value)
+	if err != nil {
+		return
+	}
+	bsonTag := lookupBSONTag(unquoted)
+	if bsonTag == "" {
+		return
+	}
+	parts := strings.Split(bsonTag, ",")
+	if parts[0] == "-" {
+		skip = true
+		return
+	}
+	name = parts[0]
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitempty = true
+		}
+	}
+	return
+}
+
+// lookupBSONTag extracts the value of a `bson:"..."` key from a struct
+// tag written in the conventional `key:"value" key2:"value2"` form,
+// mirroring bson.lookupBSONTag. If tag doesn't look like that form at
+// all, it's returned unchanged.
+func lookupBSONTag(tag string) string {
+	for tag != "" {
+		i := strings.IndexByte(tag, ':')
+		if i <= 0 || i+1 >= len(tag) || tag[i+1] != '"' {
+			break
+		}
+		name := strings.TrimSpace(tag[:i])
+		rest := tag[i+2:]
+		j := strings.IndexByte(rest, '"')
+		if j < 0 {
+			break
+		}
+		value := rest[:j]
+		if name == "bson" {
+			return value
+		}
+		tag = strings.TrimSpace(rest[j+1:])
+	}
+	return tag
+}
+
+// writeSkipElem emits a helper that advances past one element's value
+// bytes without decoding it, for document keys the generated struct
+// doesn't have a field for. It only needs to handle the kinds this
+// generator itself ever writes, plus the common kinds a hand-written
+// producer might send for an unrecognized key.
+func writeSkipElem(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "func skipElem(kind byte, r []byte) []byte {\n")
+	fmt.Fprintf(buf, "\tswitch kind {\n")
+	fmt.Fprintf(buf, "\tcase 0x01, 0x09, 0x12: // float64, datetime, int64\n")
+	fmt.Fprintf(buf, "\t\treturn r[8:]\n")
+	fmt.Fprintf(buf, "\tcase 0x02, 0x0D, 0x0E: // string, javascript, symbol\n")
+	fmt.Fprintf(buf, "\t\tl := binary.LittleEndian.Uint32(r)\n")
+	fmt.Fprintf(buf, "\t\treturn r[4+l:]\n")
+	fmt.Fprintf(buf, "\tcase 0x03, 0x04: // document, array\n")
+	fmt.Fprintf(buf, "\t\tl := binary.LittleEndian.Uint32(r)\n")
+	fmt.Fprintf(buf, "\t\treturn r[l:]\n")
+	fmt.Fprintf(buf, "\tcase 0x05: // binary\n")
+	fmt.Fprintf(buf, "\t\tl := binary.LittleEndian.Uint32(r)\n")
+	fmt.Fprintf(buf, "\t\treturn r[5+l:]\n")
+	fmt.Fprintf(buf, "\tcase 0x07: // ObjectID\n")
+	fmt.Fprintf(buf, "\t\treturn r[12:]\n")
+	fmt.Fprintf(buf, "\tcase 0x08: // bool\n")
+	fmt.Fprintf(buf, "\t\treturn r[1:]\n")
+	fmt.Fprintf(buf, "\tcase 0x0A, 0x7F, 0xFF: // null, maxkey, minkey\n")
+	fmt.Fprintf(buf, "\t\treturn r\n")
+	fmt.Fprintf(buf, "\tcase 0x10: // int32\n")
+	fmt.Fprintf(buf, "\t\treturn r[4:]\n")
+	fmt.Fprintf(buf, "\tcase 0x13: // decimal128\n")
+	fmt.Fprintf(buf, "\t\treturn r[16:]\n")
+	fmt.Fprintf(buf, "\t}\n")
+	fmt.Fprintf(buf, "\tpanic(\"bson: unsupported element kind in generated decoder\")\n")
+	fmt.Fprintf(buf, "}\n\n")
+}
+
+func writeMarshal(buf *bytes.Buffer, gs genStruct) {
+	fmt.Fprintf(buf, "// MarshalBSON implements bson.Marshaler for %s without reflection.\n", gs.name)
+	fmt.Fprintf(buf, "func (v *%s) MarshalBSON() (byte, []byte, os.Error) {\n", gs.name)
+	fmt.Fprintf(buf, "\tvar buf bytes.Buffer\n")
+	fmt.Fprintf(buf, "\tbuf.Write([]byte{0, 0, 0, 0})\n")
+	for _, f := range gs.fields {
+		if f.omitempty {
+			fmt.Fprintf(buf, "\tif !(%s) {\n", zeroCheck("v."+f.goName, f.typ))
+		}
+		writeFieldEncode(buf, f, f.omitempty)
+		if f.omitempty {
+			fmt.Fprintf(buf, "\t}\n")
+		}
+	}
+	fmt.Fprintf(buf, "\tbuf.WriteByte(0x00)\n")
+	fmt.Fprintf(buf, "\tb := buf.Bytes()\n")
+	fmt.Fprintf(buf, "\tl := int32(len(b))\n")
+	fmt.Fprintf(buf, "\tb[0], b[1], b[2], b[3] = byte(l), byte(l>>8), byte(l>>16), byte(l>>24)\n")
+	fmt.Fprintf(buf, "\treturn %#x, b, nil\n", kindDoc)
+	fmt.Fprintf(buf, "}\n\n")
+}
+
+func zeroCheck(expr, typ string) string {
+	switch typ {
+	case "string":
+		return expr + ` == ""`
+	case "bool":
+		return "!" + expr
+	case "[]byte":
+		return "len(" + expr + ") == 0"
+	default:
+		return expr + " == 0"
+	}
+}
+
+func writeFieldEncode(buf *bytes.Buffer, f field, indent bool) {
+	pad := "\t"
+	if indent {
+		pad = "\t\t"
+	}
+	expr := "v." + f.goName
+	switch f.typ {
+	case "string":
+		fmt.Fprintf(buf, "%sbuf.WriteByte(%#x)\n", pad, kindString)
+		fmt.Fprintf(buf, "%sbuf.WriteString(%q)\n", pad, f.bsonName)
+		fmt.Fprintf(buf, "%sbuf.WriteByte(0)\n", pad)
+		fmt.Fprintf(buf, "%sbinary.Write(&buf, binary.LittleEndian, int32(len(%s)+1))\n", pad, expr)
+		fmt.Fprintf(buf, "%sbuf.WriteString(%s)\n", pad, expr)
+		fmt.Fprintf(buf, "%sbuf.WriteByte(0)\n", pad)
+	case "bool":
+		fmt.Fprintf(buf, "%sbuf.WriteByte(%#x)\n", pad, kindBool)
+		fmt.Fprintf(buf, "%sbuf.WriteString(%q)\n", pad, f.bsonName)
+		fmt.Fprintf(buf, "%sbuf.WriteByte(0)\n", pad)
+		fmt.Fprintf(buf, "%sif %s {\n", pad, expr)
+		fmt.Fprintf(buf, "%s\tbuf.WriteByte(1)\n", pad)
+		fmt.Fprintf(buf, "%s} else {\n", pad)
+		fmt.Fprintf(buf, "%s\tbuf.WriteByte(0)\n", pad)
+		fmt.Fprintf(buf, "%s}\n", pad)
+	case "int32":
+		fmt.Fprintf(buf, "%sbuf.WriteByte(%#x)\n", pad, kindInt32)
+		fmt.Fprintf(buf, "%sbuf.WriteString(%q)\n", pad, f.bsonName)
+		fmt.Fprintf(buf, "%sbuf.WriteByte(0)\n", pad)
+		fmt.Fprintf(buf, "%sbinary.Write(&buf, binary.LittleEndian, %s)\n", pad, expr)
+	case "int64":
+		fmt.Fprintf(buf, "%sbuf.WriteByte(%#x)\n", pad, kindInt64)
+		fmt.Fprintf(buf, "%sbuf.WriteString(%q)\n", pad, f.bsonName)
+		fmt.Fprintf(buf, "%sbuf.WriteByte(0)\n", pad)
+		fmt.Fprintf(buf, "%sbinary.Write(&buf, binary.LittleEndian, %s)\n", pad, expr)
+	case "float64":
+		fmt.Fprintf(buf, "%sbuf.WriteByte(%#x)\n", pad, kindFloat)
+		fmt.Fprintf(buf, "%sbuf.WriteString(%q)\n", pad, f.bsonName)
+		fmt.Fprintf(buf, "%sbuf.WriteByte(0)\n", pad)
+		fmt.Fprintf(buf, "%sbinary.Write(&buf, binary.LittleEndian, %s)\n", pad, expr)
+	case "[]byte":
+		fmt.Fprintf(buf, "%sbuf.WriteByte(%#x)\n", pad, kindBinary)
+		fmt.Fprintf(buf, "%sbuf.WriteString(%q)\n", pad, f.bsonName)
+		fmt.Fprintf(buf, "%sbuf.WriteByte(0)\n", pad)
+		fmt.Fprintf(buf, "%sbinary.Write(&buf, binary.LittleEndian, int32(len(%s)))\n", pad, expr)
+		fmt.Fprintf(buf, "%sbuf.WriteByte(0)\n", pad)
+		fmt.Fprintf(buf, "%sbuf.Write(%s)\n", pad, expr)
+	}
+}
+
+func writeUnmarshal(buf *bytes.Buffer, gs genStruct) {
+	names := make([]string, len(gs.fields))
+	for i, f := range gs.fields {
+		names[i] = f.bsonName
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(buf, "// UnmarshalBSON implements bson.Unmarshaler for %s without\n", gs.name)
+	fmt.Fprintf(buf, "// reflection, the decode counterpart of MarshalBSON.\n")
+	fmt.Fprintf(buf, "func (v *%s) UnmarshalBSON(kind byte, data []byte) os.Error {\n", gs.name)
+	fmt.Fprintf(buf, "\tif kind != %#x {\n", kindDoc)
+	fmt.Fprintf(buf, "\t\treturn os.NewError(\"bson: invalid kind for %s\")\n", gs.name)
+	fmt.Fprintf(buf, "\t}\n")
+	fmt.Fprintf(buf, "\tr := data[4:]\n")
+	fmt.Fprintf(buf, "\tfor len(r) > 0 && r[0] != 0 {\n")
+	fmt.Fprintf(buf, "\t\tkind := r[0]\n")
+	fmt.Fprintf(buf, "\t\tr = r[1:]\n")
+	fmt.Fprintf(buf, "\t\tend := bytes.IndexByte(r, 0)\n")
+	fmt.Fprintf(buf, "\t\tkey := string(r[:end])\n")
+	fmt.Fprintf(buf, "\t\tr = r[end+1:]\n")
+	fmt.Fprintf(buf, "\t\tswitch key {\n")
+	for _, f := range gs.fields {
+		fmt.Fprintf(buf, "\t\tcase %q:\n", f.bsonName)
+		writeFieldDecode(buf, f)
+	}
+	fmt.Fprintf(buf, "\t\tdefault:\n")
+	fmt.Fprintf(buf, "\t\t\tr = skipElem(kind, r)\n")
+	fmt.Fprintf(buf, "\t\t}\n")
+	fmt.Fprintf(buf, "\t}\n")
+	fmt.Fprintf(buf, "\treturn nil\n")
+	fmt.Fprintf(buf, "}\n\n")
+}
+
+func writeFieldDecode(buf *bytes.Buffer, f field) {
+	expr := "v." + f.goName
+	switch f.typ {
+	case "string":
+		fmt.Fprintf(buf, "\t\t\tl := binary.LittleEndian.Uint32(r)\n")
+		fmt.Fprintf(buf, "\t\t\t%s = string(r[4 : 4+l-1])\n", expr)
+		fmt.Fprintf(buf, "\t\t\tr = r[4+l:]\n")
+	case "bool":
+		fmt.Fprintf(buf, "\t\t\t%s = r[0] != 0\n", expr)
+		fmt.Fprintf(buf, "\t\t\tr = r[1:]\n")
+	case "int32":
+		fmt.Fprintf(buf, "\t\t\t%s = int32(binary.LittleEndian.Uint32(r))\n", expr)
+		fmt.Fprintf(buf, "\t\t\tr = r[4:]\n")
+	case "int64":
+		fmt.Fprintf(buf, "\t\t\t%s = int64(binary.LittleEndian.Uint64(r))\n", expr)
+		fmt.Fprintf(buf, "\t\t\tr = r[8:]\n")
+	case "float64":
+		fmt.Fprintf(buf, "\t\t\tbits := binary.LittleEndian.Uint64(r)\n")
+		fmt.Fprintf(buf, "\t\t\t%s = math.Float64frombits(bits)\n", expr)
+		fmt.Fprintf(buf, "\t\t\tr = r[8:]\n")
+	case "[]byte":
+		fmt.Fprintf(buf, "\t\t\tl := binary.LittleEndian.Uint32(r)\n")
+		fmt.Fprintf(buf, "\t\t\t%s = append([]byte(nil), r[5:5+l]...)\n", expr)
+		fmt.Fprintf(buf, "\t\t\tr = r[5+l:]\n")
+	}
+}
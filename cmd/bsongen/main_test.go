@@ -0,0 +1,43 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"testing"
+)
+
+func tagLit(raw string) *ast.BasicLit {
+	return &ast.BasicLit{Kind: token.STRING, Value: "`" + raw + "`"}
+}
+
+func TestParseFieldTagBareForm(t *testing.T) {
+	name, omitempty, skip := parseFieldTag(tagLit("n,omitempty"))
+	if name != "n" || !omitempty || skip {
+		t.Errorf("parseFieldTag(bare) = (%q, %v, %v), want (\"n\", true, false)", name, omitempty, skip)
+	}
+}
+
+func TestParseFieldTagBSONKeyForm(t *testing.T) {
+	name, omitempty, skip := parseFieldTag(tagLit(`bson:"n,omitempty"`))
+	if name != "n" || !omitempty || skip {
+		t.Errorf("parseFieldTag(bson:) = (%q, %v, %v), want (\"n\", true, false)", name, omitempty, skip)
+	}
+}
+
+func TestParseFieldTagSkip(t *testing.T) {
+	name, _, skip := parseFieldTag(tagLit("-"))
+	if name != "" || !skip {
+		t.Errorf("parseFieldTag(-) = (%q, _, %v), want (\"\", true)", name, skip)
+	}
+}
+
+func TestParseFieldTagNil(t *testing.T) {
+	name, omitempty, skip := parseFieldTag(nil)
+	if name != "" || omitempty || skip {
+		t.Errorf("parseFieldTag(nil) = (%q, %v, %v), want zero values", name, omitempty, skip)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+)
+
+// DocElem is a single named element of an ordered document; see D.
+type DocElem struct {
+	Name  string
+	Value interface{}
+}
+
+// D represents a BSON document as an ordered list of elements, for
+// callers that need a deterministic key order on the wire (index
+// specs, command documents, signed payloads) that a plain map can't
+// guarantee. Marshal writes a D's elements in the given order, and
+// Unmarshal into a *D returns them back in wire order.
+type D []DocElem
+
+// M is a convenient alias for the common case of an unordered document.
+type M map[string]interface{}
+
+// Map converts d to an M, discarding its ordering. Elements with a
+// repeated Name overwrite earlier ones, as with Unmarshal into a map.
+func (d D) Map() M {
+	m := make(M, len(d))
+	for _, e := range d {
+		m[e.Name] = e.Value
+	}
+	return m
+}
+
+var dType = reflect.Typeof(D(nil))
+
+// writeDocElems writes each element of d as a key/value pair, in order.
+func (e *encodeState) writeDocElems(d D) {
+	for _, el := range d {
+		e.writeKeyVal(el.Name, el.Value)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMarshalDPreservesOrder(t *testing.T) {
+	doc := D{{"b", int32(2)}, {"a", int32(1)}}
+	got, err := Marshal(doc)
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err.String())
+	}
+	want := []byte("\x13\x00\x00\x00\x10b\x00\x02\x00\x00\x00\x10a\x00\x01\x00\x00\x00\x00")
+	if !bytes.Equal(got, want) {
+		t.Errorf("Marshal(%v) = %q, want %q", doc, got, want)
+	}
+}
+
+func TestUnmarshalDPreservesOrder(t *testing.T) {
+	b, err := Marshal(D{{"b", int32(2)}, {"a", int32(1)}})
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err.String())
+	}
+
+	var doc D
+	if err := Unmarshal(b, &doc); err != nil {
+		t.Fatalf("Unmarshal error: %s", err.String())
+	}
+	if len(doc) != 2 || doc[0].Name != "b" || doc[1].Name != "a" {
+		t.Errorf("Unmarshal got %+v, want [b a] order", doc)
+	}
+}
+
+func TestDMap(t *testing.T) {
+	m := D{{"a", 1}, {"b", 2}}.Map()
+	if m["a"] != 1 || m["b"] != 2 {
+		t.Errorf("Map() = %+v, want a=1 b=2", m)
+	}
+}
+
+func TestNestedD(t *testing.T) {
+	b, err := Marshal(map[string]interface{}{"inner": D{{"x", int32(5)}}})
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err.String())
+	}
+
+	var doc struct {
+		Inner map[string]interface{}
+	}
+	if err := Unmarshal(b, &doc); err != nil {
+		t.Fatalf("Unmarshal error: %s", err.String())
+	}
+	if doc.Inner["x"] != int32(5) {
+		t.Errorf("doc.Inner[\"x\"] = %v, want 5", doc.Inner["x"])
+	}
+}
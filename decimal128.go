@@ -0,0 +1,248 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Decimal128 represents the BSON 128-bit IEEE 754-2008 decimal floating
+// point type. The two halves are stored exactly as they appear on the
+// wire: L holds the low-order 64 bits, H holds the high-order 64 bits
+// (sign, combination field and the high bits of the coefficient).
+type Decimal128 struct {
+	H, L uint64
+}
+
+func (d Decimal128) MarshalBSON() (byte, []byte, os.Error) {
+	b := make([]byte, 16)
+	order.PutUint64(b, d.L)
+	order.PutUint64(b[8:], d.H)
+	return 0x13, b, nil
+}
+
+const (
+	dec128ExponentBias = 6176
+	dec128MaxBiasedExp = 0x3fff
+)
+
+// String formats d using the canonical decimal128 text representation
+// described by the IEEE 754-2008 spec, which is also what MongoDB's
+// extended JSON uses.
+func (d Decimal128) String() string {
+	sign := ""
+	if d.H>>63&1 == 1 {
+		sign = "-"
+	}
+
+	comb := uint32(d.H>>58) & 0x1f
+	if comb == 0x1f {
+		if d.H>>57&1 == 1 {
+			return sign + "NaN"
+		}
+		return sign + "Infinity"
+	}
+
+	var exponent int
+	var high uint64
+	if comb>>3 == 0x3 {
+		// Bits 1-2 of the combination field are "11": the coefficient's
+		// implicit leading bits are "100" and the exponent occupies the
+		// next 14 bits.
+		exponent = int(d.H>>47) & dec128MaxBiasedExp
+		high = uint64(0x4)<<47 | d.H&(uint64(1)<<47-1)
+	} else {
+		exponent = int(d.H>>49) & dec128MaxBiasedExp
+		high = d.H & (uint64(1)<<49 - 1)
+	}
+	exponent -= dec128ExponentBias
+
+	digits := coefficientDigits(high, d.L)
+	return sign + formatDecimal128(digits, exponent)
+}
+
+// coefficientDigits renders the 128-bit unsigned coefficient (hi:lo) as a
+// decimal string with no leading zeros, by repeated division by ten.
+func coefficientDigits(hi, lo uint64) string {
+	if hi == 0 && lo == 0 {
+		return "0"
+	}
+	var digits [40]byte
+	i := len(digits)
+	for hi != 0 || lo != 0 {
+		var rem uint64
+		hi, lo, rem = div128by10(hi, lo)
+		i--
+		digits[i] = byte('0' + rem)
+	}
+	return string(digits[i:])
+}
+
+func div128by10(hi, lo uint64) (nhi, nlo, rem uint64) {
+	hiHigh, hiLow := hi>>32, hi&0xffffffff
+	loHigh, loLow := lo>>32, lo&0xffffffff
+
+	r := hiHigh
+	q3, r := r/10, r%10
+
+	r = r<<32 | hiLow
+	q2, r := r/10, r%10
+
+	r = r<<32 | loHigh
+	q1, r := r/10, r%10
+
+	r = r<<32 | loLow
+	q0, r := r/10, r%10
+
+	return q3<<32 | q2, q1<<32 | q0, r
+}
+
+func mul128by10add(hi, lo, d uint64) (nhi, nlo uint64) {
+	loLow, loHigh := lo&0xffffffff, lo>>32
+	hiLow, hiHigh := hi&0xffffffff, hi>>32
+
+	t := loLow*10 + d
+	r0, carry := t&0xffffffff, t>>32
+
+	t = loHigh*10 + carry
+	r1, carry := t&0xffffffff, t>>32
+
+	t = hiLow*10 + carry
+	r2, carry := t&0xffffffff, t>>32
+
+	t = hiHigh*10 + carry
+	r3 := t & 0xffffffff
+
+	return r2 | r3<<32, r0 | r1<<32
+}
+
+// formatDecimal128 renders digits*10^exponent following the plain-vs-
+// scientific notation rules from the spec: plain notation is used when
+// the exponent is non-positive and the adjusted exponent (exponent plus
+// the number of digits minus one) is at least -6; scientific notation is
+// used otherwise.
+func formatDecimal128(digits string, exponent int) string {
+	nDigits := len(digits)
+	adjExp := exponent + nDigits - 1
+
+	if exponent <= 0 && adjExp >= -6 {
+		switch {
+		case exponent == 0:
+			return digits
+		case -exponent < nDigits:
+			return digits[:nDigits+exponent] + "." + digits[nDigits+exponent:]
+		default:
+			return "0." + strings.Repeat("0", -exponent-nDigits) + digits
+		}
+	}
+
+	mantissa := digits[:1]
+	if nDigits > 1 {
+		mantissa += "." + digits[1:]
+	}
+	expSign := "+"
+	if adjExp < 0 {
+		expSign = "-"
+		adjExp = -adjExp
+	}
+	return mantissa + "E" + expSign + strconv.Itoa(adjExp)
+}
+
+// ParseDecimal128 parses the canonical decimal128 text representation,
+// including the special "NaN" and "Infinity" forms.
+func ParseDecimal128(s string) (Decimal128, os.Error) {
+	orig := s
+	neg := false
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if len(s) == 0 {
+		return Decimal128{}, os.NewError("bson: invalid decimal128 string " + orig)
+	}
+
+	switch strings.ToLower(s) {
+	case "nan":
+		return newDecimal128(neg, true, false, "", 0)
+	case "inf", "infinity":
+		return newDecimal128(neg, false, true, "", 0)
+	}
+
+	digits, exponent, err := parseDecimal128Digits(s)
+	if err != nil {
+		return Decimal128{}, os.NewError("bson: invalid decimal128 string " + orig)
+	}
+	return newDecimal128(neg, false, false, digits, exponent)
+}
+
+func parseDecimal128Digits(s string) (digits string, exponent int, err os.Error) {
+	mant := s
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mant = s[:i]
+		exponent, err = strconv.Atoi(s[i+1:])
+		if err != nil {
+			return "", 0, err
+		}
+	}
+
+	if dot := strings.IndexByte(mant, '.'); dot >= 0 {
+		frac := mant[dot+1:]
+		mant = mant[:dot] + frac
+		exponent -= len(frac)
+	}
+	if mant == "" {
+		return "", 0, os.NewError("bson: no digits")
+	}
+	for i := 0; i < len(mant); i++ {
+		if mant[i] < '0' || mant[i] > '9' {
+			return "", 0, os.NewError("bson: invalid digit")
+		}
+	}
+
+	i := 0
+	for i < len(mant)-1 && mant[i] == '0' {
+		i++
+	}
+	return mant[i:], exponent, nil
+}
+
+func newDecimal128(neg, nan, inf bool, digits string, exponent int) (Decimal128, os.Error) {
+	var h, l uint64
+	if neg {
+		h |= uint64(1) << 63
+	}
+	if nan {
+		h |= uint64(0x1f)<<58 | uint64(1)<<57
+		return Decimal128{h, l}, nil
+	}
+	if inf {
+		h |= uint64(0x1f) << 58
+		return Decimal128{h, l}, nil
+	}
+
+	if digits == "" {
+		digits = "0"
+	}
+	exponent += dec128ExponentBias
+	if exponent < 0 || exponent > dec128MaxBiasedExp {
+		return Decimal128{}, os.NewError("bson: decimal128 exponent out of range")
+	}
+
+	var hi, lo uint64
+	for i := 0; i < len(digits); i++ {
+		hi, lo = mul128by10add(hi, lo, uint64(digits[i]-'0'))
+	}
+
+	if hi>>49 != 0 {
+		h |= uint64(0x3)<<61 | uint64(exponent)<<47 | hi&(uint64(1)<<47-1)
+	} else {
+		h |= uint64(exponent)<<49 | hi
+	}
+	l = lo
+
+	return Decimal128{h, l}, nil
+}
@@ -77,11 +77,31 @@ func (d *decodeState) decodeDoc(val reflect.Value) {
 		d.decodeMapDoc(v)
 	case *reflect.StructValue:
 		d.decodeStructDoc(v)
+	case *reflect.SliceValue:
+		if v.Type() != dType {
+			d.error(&InvalidUnmarshalError{val.Type()})
+			return
+		}
+		d.decodeOrderedDoc(v)
 	default:
 		d.error(&InvalidUnmarshalError{val.Type()})
 	}
 }
 
+// decodeOrderedDoc decodes a document into v, a *D, appending elements
+// in wire order instead of keying them by name.
+func (d *decodeState) decodeOrderedDoc(v *reflect.SliceValue) {
+	s := v
+	kind, key, b := d.readChunk()
+	for kind > 0 {
+		val := d.decodeElemInterface(kind, b)
+		el := reflect.NewValue(DocElem{key, val})
+		s = reflect.Append(s, el)
+		kind, key, b = d.readChunk()
+	}
+	v.SetValue(s)
+}
+
 func (d *decodeState) decodeMapDoc(v *reflect.MapValue) {
 	mapType := v.Type().(*reflect.MapType)
 	_, stringKey := mapType.Key().(*reflect.StringType)
@@ -102,32 +122,77 @@ func (d *decodeState) decodeMapDoc(v *reflect.MapValue) {
 
 func (d *decodeState) decodeStructDoc(v *reflect.StructValue) {
 	st := v.Type().(*reflect.StructType)
+	var inline reflect.Value
+	hasInline := false
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		if fieldTag(f).inline {
+			inline = v.FieldByIndex(f.Index)
+			hasInline = true
+		}
+	}
 
 	kind, key, b := d.readChunk()
 	for kind > 0 {
-		var fieldVal reflect.Value
-		var f reflect.StructField
-		found := false
-		for i := 0; i < st.NumField(); i++ {
-			f = st.Field(i)
-			if f.Tag == key {
-				found = true
-				break
-			}
+		if fieldVal, found := findStructField(v, key); found {
+			d.decodeElem(kind, b, fieldVal)
+		} else if hasInline {
+			d.decodeInlineField(inline, key, kind, b)
+		}
+
+		kind, key, b = d.readChunk()
+	}
+}
+
+// findStructField looks up the field of v that document key key should
+// decode into, trying an exact tag-name match first, then an exact Go
+// field name match, then a case-insensitive name match. Fields tagged
+// "skip" or "inline" are never matched directly.
+func findStructField(v *reflect.StructValue, key string) (reflect.Value, bool) {
+	st := v.Type().(*reflect.StructType)
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		opts := fieldTag(f)
+		if opts.skip || opts.inline {
+			continue
 		}
-		if !found {
-			f, found = st.FieldByName(key)
+		if opts.name == key {
+			return v.FieldByIndex(f.Index), true
 		}
-		if !found {
-			lowKey := strings.ToLower(key)
-			f, found = st.FieldByNameFunc(func(s string) bool { return lowKey == strings.ToLower(s) })
+	}
+	if f, found := st.FieldByName(key); found {
+		if opts := fieldTag(f); !opts.skip && !opts.inline {
+			return v.FieldByIndex(f.Index), true
 		}
-		if found {
-			fieldVal = v.FieldByIndex(f.Index)
-			d.decodeElem(kind, b, fieldVal)
+	}
+	lowKey := strings.ToLower(key)
+	if f, found := st.FieldByNameFunc(func(s string) bool { return lowKey == strings.ToLower(s) }); found {
+		if opts := fieldTag(f); !opts.skip && !opts.inline {
+			return v.FieldByIndex(f.Index), true
 		}
+	}
+	return nil, false
+}
 
-		kind, key, b = d.readChunk()
+// decodeInlineField stores a document element that didn't match any
+// regular field into the struct's "inline" field, which must be a map
+// with a string key or a struct (or a pointer to either) whose own
+// fields are matched the same way decodeStructDoc matches top-level
+// ones.
+func (d *decodeState) decodeInlineField(inline reflect.Value, key string, kind byte, b []byte) {
+	switch v := indirect(inline).(type) {
+	case *reflect.MapValue:
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type().(*reflect.MapType)))
+		}
+		elType := v.Type().(*reflect.MapType).Elem()
+		val := reflect.MakeZero(elType)
+		d.decodeElem(kind, b, val)
+		v.SetElem(reflect.NewValue(key), val)
+	case *reflect.StructValue:
+		if fieldVal, found := findStructField(v, key); found {
+			d.decodeElem(kind, b, fieldVal)
+		}
 	}
 }
 
@@ -173,6 +238,7 @@ var lengths = []int{
 	elJavaScope:  lengthEncodedMinus,
 	elInt32:      4,
 	elInt64:      8,
+	elDecimal128: 16,
 	elMax:        0,
 	elMin:        0,
 }
@@ -217,6 +283,56 @@ func (d *decodeState) decodeElem(kind byte, b []byte, val reflect.Value) {
 		return
 	}
 
+	if c, ok := lookupCodec(val.Type()); ok {
+		d.error(c.decode(kind, b, val))
+		return
+	}
+	if s, ok := val.Interface().(Setter); ok {
+		d.error(s.SetBSON(Raw{kind, b}))
+		return
+	}
+	if u, ok := val.Interface().(Unmarshaler); ok {
+		d.error(u.UnmarshalBSON(kind, prependLength(kind, b)))
+		return
+	}
+
+	if val.Type() == binaryType {
+		if kind != elBinary {
+			panic("invalid type for decoding")
+		}
+		subtype, data := binaryPayload(b)
+		val.SetValue(reflect.NewValue(Binary{subtype, data}))
+		return
+	}
+	if val.Type() == uuidType {
+		if kind != elBinary {
+			panic("invalid type for decoding")
+		}
+		_, data := binaryPayload(b)
+		if len(data) != 16 {
+			panic("invalid UUID length")
+		}
+		var u UUID
+		copy(u[:], data)
+		val.SetValue(reflect.NewValue(u))
+		return
+	}
+
+	if val.Type() == rawType {
+		val.SetValue(reflect.NewValue(Raw{kind, b}))
+		return
+	}
+	if val.Type() == rawDocumentType {
+		if kind != elDoc {
+			panic("invalid type for decoding")
+		}
+		full := make([]byte, 4+len(b))
+		order.PutUint32(full, uint32(len(full)))
+		copy(full[4:], b)
+		val.SetValue(reflect.NewValue(RawDocument(full)))
+		return
+	}
+
 	switch kind {
 	case elFloat:
 		f := math.Float64frombits(order.Uint64(b))
@@ -255,7 +371,8 @@ func (d *decodeState) decodeElem(kind byte, b []byte, val reflect.Value) {
 		if !ok {
 			goto error
 		}
-		bv := reflect.NewValue(b[1:])
+		_, data := binaryPayload(b)
+		bv := reflect.NewValue(data)
 		sliceType := sv.Type().(*reflect.SliceType)
 		if sliceType != bv.Type() {
 			goto error
@@ -365,6 +482,13 @@ func (d *decodeState) decodeElem(kind byte, b []byte, val reflect.Value) {
 			}
 			v.Set(n)
 		}
+	case elDecimal128:
+		dec := Decimal128{H: order.Uint64(b[8:]), L: order.Uint64(b)}
+		dv := reflect.NewValue(dec)
+		if val.Type() != dv.Type() {
+			goto error
+		}
+		val.SetValue(dv)
 	case elMax:
 		m := MaxKey{}
 		mv := reflect.NewValue(m)
@@ -410,11 +534,18 @@ func (d *decodeState) decodeElemInterface(kind byte, b []byte) interface{} {
 		}
 		return s
 	case elBinary:
-		// assuming binary/generic data; discarding actual kind
-		// TODO: consider making a copy of this data so that we won't
-		// be holding references to potentially large blocks of
-		// memory
-		return b[1:]
+		if zero, ok := binarySubtypes[b[0]]; ok {
+			v := zero()
+			if s, ok := v.(Setter); ok {
+				d.error(s.SetBSON(Raw{elBinary, b}))
+				return v
+			}
+		}
+		subtype, data := binaryPayload(b)
+		if subtype == BinaryGeneric {
+			return data
+		}
+		return Binary{subtype, data}
 	case elObjectID:
 		var o ObjectID
 		copy(o[:], b)
@@ -447,6 +578,8 @@ func (d *decodeState) decodeElemInterface(kind byte, b []byte) interface{} {
 		return int32(order.Uint32(b))
 	case elInt64:
 		return int64(order.Uint64(b))
+	case elDecimal128:
+		return Decimal128{H: order.Uint64(b[8:]), L: order.Uint64(b)}
 	case elMax:
 		return MaxKey{}
 	case elMin:
@@ -51,18 +51,15 @@ unboxing:
 		case *reflect.PtrValue:
 			rval = v.Elem()
 		case *reflect.StructValue:
-			t := rval.Type().(*reflect.StructType)
-			for i := 0; i < t.NumField(); i++ {
-				field := t.Field(i)
-				/*if field.Anonymous {
-					continue
-				}*/
-				key := field.Tag
-				if key == "" {
-					key = field.Name
-				}
-				e.writeKeyVal(key, v.Field(i).Interface())
+			if err := e.writeStructFields(v); err != nil {
+				return err
+			}
+			break unboxing
+		case *reflect.SliceValue:
+			if v.Type() != dType {
+				panic("invalid type")
 			}
+			e.writeDocElems(v.Interface().(D))
 			break unboxing
 		default:
 			panic("invalid type")
@@ -78,6 +75,96 @@ unboxing:
 	return nil
 }
 
+// writeStructFields writes each field of v as a key/value pair, honoring
+// the tag options parsed from each field's bson tag: a name of "-" skips
+// the field, "omitempty" skips zero values, "inline" splices an embedded
+// struct or map's fields directly into e instead of nesting them, and
+// "minsize" down-encodes an int/int64 as a BSON int32 when it fits. It
+// returns an error if an inline field's keys collide with a field
+// already written, since the duplicate-key document that would produce
+// can't be decoded back unambiguously.
+func (e *encodeState) writeStructFields(v *reflect.StructValue) os.Error {
+	return e.writeStructFieldsSeen(v, make(map[string]bool))
+}
+
+func (e *encodeState) writeStructFieldsSeen(v *reflect.StructValue, seen map[string]bool) os.Error {
+	t := v.Type().(*reflect.StructType)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		opts := fieldTag(field)
+		if opts.skip {
+			continue
+		}
+		fval := v.Field(i)
+		if opts.omitempty && isEmptyValue(fval) {
+			continue
+		}
+		if opts.inline {
+			switch fv := indirectEncode(fval).(type) {
+			case *reflect.StructValue:
+				if err := e.writeStructFieldsSeen(fv, seen); err != nil {
+					return err
+				}
+				continue
+			case *reflect.MapValue:
+				for _, rkey := range fv.Keys() {
+					name := rkey.Interface().(string)
+					if seen[name] {
+						return os.NewError("bson: inline field collides with key \"" + name + "\"")
+					}
+					seen[name] = true
+					e.writeKeyVal(name, fv.Elem(rkey).Interface())
+				}
+				continue
+			}
+		}
+		if seen[opts.name] {
+			return os.NewError("bson: inline field collides with key \"" + opts.name + "\"")
+		}
+		seen[opts.name] = true
+		val := fval.Interface()
+		if opts.minsize {
+			val = minsizeValue(val)
+		}
+		e.writeKeyVal(opts.name, val)
+	}
+	return nil
+}
+
+// indirectEncode dereferences pointers so inline fields can be typed as
+// either a struct/map value or a pointer to one.
+func indirectEncode(v reflect.Value) reflect.Value {
+	for {
+		pv, ok := v.(*reflect.PtrValue)
+		if !ok {
+			return v
+		}
+		v = pv.Elem()
+	}
+	panic("unreachable")
+}
+
+// minsizeValue down-converts int and int64 values that fit into int32,
+// leaving everything else untouched.
+func minsizeValue(val interface{}) interface{} {
+	switch v := val.(type) {
+	case int:
+		if int64(v) >= minInt32 && int64(v) <= maxInt32 {
+			return int32(v)
+		}
+	case int64:
+		if v >= minInt32 && v <= maxInt32 {
+			return int32(v)
+		}
+	}
+	return val
+}
+
+const (
+	minInt32 = -1 << 31
+	maxInt32 = 1<<31 - 1
+)
+
 func (e *encodeState) writeBegin(kind byte, key string) os.Error {
 	e.WriteByte(kind)
 	e.WriteString(key)
@@ -85,7 +172,33 @@ func (e *encodeState) writeBegin(kind byte, key string) os.Error {
 }
 
 func (e *encodeState) writeKeyVal(key string, val interface{}) os.Error {
+	if val != nil {
+		if c, ok := lookupCodec(reflect.Typeof(val)); ok {
+			kind, b, err := c.encode(val)
+			if err != nil {
+				return err
+			}
+			e.writeBegin(kind, key)
+			_, err = e.Write(b)
+			return err
+		}
+	}
+
 	switch v := val.(type) {
+	case D:
+		e.writeBegin(elDoc, key)
+		e2 := &encodeState{bytes.NewBuffer(nil)}
+		e2.writeDocElems(v)
+		b := e2.Bytes()
+		binary.Write(e, order, int32(len(b)+5))
+		e.Write(b)
+		return e.WriteByte(0x00)
+	case Getter:
+		sub, err := v.GetBSON()
+		if err != nil {
+			return err
+		}
+		return e.writeKeyVal(key, sub)
 	case Marshaler:
 		kind, b, err := v.MarshalBSON()
 		if err != nil {
@@ -188,16 +301,11 @@ func (e *encodeState) writeReflect(key string, val reflect.Value) os.Error {
 		return e.writeKeyVal(key, v.Elem().Interface())
 	case *reflect.StructValue:
 		e.writeBegin(elDoc, key)
-		t := v.Type().(*reflect.StructType)
-		l := t.NumField()
-		for i := 0; i < l; i++ {
-			field := t.Field(i)
-			name := field.Tag
-			if name == "" {
-				name = field.Name
-			}
-			e.writeKeyVal(name, v.Field(i).Interface())
+		e2 := &encodeState{bytes.NewBuffer(nil)}
+		if err := e2.writeStructFields(v); err != nil {
+			return err
 		}
+		e.Write(e2.Bytes())
 		return e.WriteByte(0x00)
 	}
 	return &UnsupportedTypeError{val.Type()}
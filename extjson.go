@@ -0,0 +1,433 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MarshalExtJSON returns the MongoDB Extended JSON encoding of v, the
+// format used by mongoexport, mongodump --jsonArray and bsondump.
+//
+// In canonical mode every value is written with an explicit type
+// wrapper (e.g. an int32 as {"$numberInt":"1"}), so the result decodes
+// back to exactly the same BSON types. In relaxed mode values that can
+// be represented losslessly as plain JSON -- ints and finite doubles as
+// JSON numbers, dates as ISO-8601 strings -- are written that way
+// instead, at the cost of the BSON subtype not surviving a round trip
+// through UnmarshalExtJSON.
+func MarshalExtJSON(v interface{}, canonical bool) ([]byte, os.Error) {
+	b, err := Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	doc := docToExtJSON(b[4:], canonical)
+	out, jerr := json.Marshal(doc)
+	if jerr != nil {
+		return nil, os.NewError(jerr.String())
+	}
+	return out, nil
+}
+
+// UnmarshalExtJSON parses MongoDB Extended JSON data and stores the
+// result in v, as with Unmarshal. Both canonical and relaxed type
+// wrappers are accepted, as well as the legacy {"$date":"...ISO..."}
+// form some older tools emit in place of {"$date":{"$numberLong":...}}.
+func UnmarshalExtJSON(data []byte, v interface{}) os.Error {
+	var tree interface{}
+	if jerr := json.Unmarshal(data, &tree); jerr != nil {
+		return os.NewError(jerr.String())
+	}
+	doc, err := fromExtJSON(tree)
+	if err != nil {
+		return err
+	}
+	b, err := Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(b, v)
+}
+
+// docToExtJSON converts the elements of a BSON document's bytes (not
+// including its leading length prefix) to an Extended JSON-ready map.
+func docToExtJSON(b []byte, canonical bool) map[string]interface{} {
+	m := make(map[string]interface{})
+	d := &decodeState{b: b}
+	kind, key, eb := d.readChunk()
+	for kind > 0 {
+		m[key] = extJSONElem(kind, eb, canonical)
+		kind, key, eb = d.readChunk()
+	}
+	return m
+}
+
+// arrayToExtJSON is docToExtJSON's counterpart for a BSON array.
+func arrayToExtJSON(b []byte, canonical bool) []interface{} {
+	var s []interface{}
+	d := &decodeState{b: b}
+	kind, _, eb := d.readChunk()
+	for kind > 0 {
+		s = append(s, extJSONElem(kind, eb, canonical))
+		kind, _, eb = d.readChunk()
+	}
+	return s
+}
+
+// extJSONElem converts a single decoded BSON element to its Extended
+// JSON representation, mirroring decodeElemInterface's type switch.
+func extJSONElem(kind byte, b []byte, canonical bool) interface{} {
+	switch kind {
+	case elFloat:
+		f := math.Float64frombits(order.Uint64(b))
+		if canonical || math.IsInf(f, 0) || f != f {
+			return map[string]interface{}{"$numberDouble": formatExtJSONDouble(f)}
+		}
+		return f
+	case elString:
+		return string(b[:len(b)-1])
+	case elDoc:
+		return docToExtJSON(b, canonical)
+	case elArray:
+		return arrayToExtJSON(b, canonical)
+	case elBinary:
+		subtype, data := binaryPayload(b)
+		return map[string]interface{}{
+			"$binary": map[string]interface{}{
+				"base64":  base64.StdEncoding.EncodeToString(data),
+				"subType": hex.EncodeToString([]byte{subtype}),
+			},
+		}
+	case elObjectID:
+		return map[string]interface{}{"$oid": hex.EncodeToString(b)}
+	case elBool:
+		return b[0] != 0
+	case elDatetime:
+		return dateToExtJSON(int64(order.Uint64(b)), canonical)
+	case elNull:
+		return nil
+	case elRegexp:
+		pos := bytes.IndexByte(b, 0)
+		pattern := string(b[:pos])
+		options := string(b[pos+1 : len(b)-1])
+		return map[string]interface{}{
+			"$regularExpression": map[string]interface{}{
+				"pattern": pattern,
+				"options": options,
+			},
+		}
+	case elJavaScript:
+		return map[string]interface{}{"$code": string(b[:len(b)-1])}
+	case elSymbol:
+		return map[string]interface{}{"$symbol": string(b[:len(b)-1])}
+	case elJavaScope:
+		d2 := &decodeState{b: b}
+		code := d2.readString()
+		d2.r += 4 // discard scope document's own length prefix
+		scope := docToExtJSON(d2.b[d2.r:], canonical)
+		return map[string]interface{}{"$code": code, "$scope": scope}
+	case elInt32:
+		n := int32(order.Uint32(b))
+		if canonical {
+			return map[string]interface{}{"$numberInt": strconv.Itoa(int(n))}
+		}
+		return n
+	case elInt64:
+		n := int64(order.Uint64(b))
+		if !canonical && n >= -1<<53 && n <= 1<<53 {
+			return n
+		}
+		return map[string]interface{}{"$numberLong": strconv.Itoa64(n)}
+	case elDecimal128:
+		dec := Decimal128{H: order.Uint64(b[8:]), L: order.Uint64(b)}
+		return map[string]interface{}{"$numberDecimal": dec.String()}
+	case elMax:
+		return map[string]interface{}{"$maxKey": 1}
+	case elMin:
+		return map[string]interface{}{"$minKey": 1}
+	}
+	panic("bson: unsupported type for extended JSON")
+}
+
+// formatExtJSONDouble renders f the way $numberDouble expects: the
+// special values as bare words, everything else via strconv.
+func formatExtJSONDouble(f float64) string {
+	switch {
+	case f != f:
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return strconv.Ftoa64(f, 'g', -1)
+}
+
+// dateToExtJSON converts a BSON datetime, stored by this package as
+// seconds since the epoch, to its Extended JSON form. Canonical mode
+// (and any date outside the range a zero-padded 4-digit year can
+// express) always uses {"$date":{"$numberLong":"<millis>"}}; relaxed
+// mode uses an ISO-8601 string when the year fits.
+func dateToExtJSON(seconds int64, canonical bool) interface{} {
+	millis := strconv.Itoa64(seconds * 1000)
+	if !canonical {
+		t := time.SecondsToUTC(seconds)
+		if t.Year >= 0 && t.Year <= 9999 {
+			return map[string]interface{}{"$date": formatISO8601(t)}
+		}
+	}
+	return map[string]interface{}{"$date": map[string]interface{}{"$numberLong": millis}}
+}
+
+// formatISO8601 renders t, which must be UTC, as an ISO-8601 timestamp.
+func formatISO8601(t *time.Time) string {
+	return fmt.Sprintf("%04d-%02d-%02dT%02d:%02d:%02dZ",
+		t.Year, t.Month, t.Day, t.Hour, t.Minute, t.Second)
+}
+
+// parseISO8601 parses the timestamp forms Extended JSON's legacy
+// {"$date":"..."} uses: a UTC "Z" offset or a numeric +HH:MM/-HH:MM
+// offset, with an optional fractional-second component that is
+// accepted but discarded, since this package's datetime only has
+// second resolution.
+func parseISO8601(s string) (int64, os.Error) {
+	invalid := os.NewError("bson: invalid date string " + s)
+	if len(s) < 20 || s[4] != '-' || s[7] != '-' || s[10] != 'T' || s[13] != ':' || s[16] != ':' {
+		return 0, invalid
+	}
+	year, err1 := strconv.Atoi(s[0:4])
+	month, err2 := strconv.Atoi(s[5:7])
+	day, err3 := strconv.Atoi(s[8:10])
+	hour, err4 := strconv.Atoi(s[11:13])
+	minute, err5 := strconv.Atoi(s[14:16])
+	second, err6 := strconv.Atoi(s[17:19])
+	if err1 != nil || err2 != nil || err3 != nil || err4 != nil || err5 != nil || err6 != nil {
+		return 0, invalid
+	}
+
+	rest := s[19:]
+	if strings.HasPrefix(rest, ".") {
+		i := 1
+		for i < len(rest) && rest[i] >= '0' && rest[i] <= '9' {
+			i++
+		}
+		rest = rest[i:]
+	}
+
+	offset := 0
+	switch {
+	case rest == "Z":
+	case len(rest) == 6 && (rest[0] == '+' || rest[0] == '-'):
+		oh, eh := strconv.Atoi(rest[1:3])
+		om, em := strconv.Atoi(rest[4:6])
+		if eh != nil || em != nil || rest[3] != ':' {
+			return 0, invalid
+		}
+		offset = oh*3600 + om*60
+		if rest[0] == '-' {
+			offset = -offset
+		}
+	default:
+		return 0, invalid
+	}
+
+	t := &time.Time{Year: int64(year), Month: month, Day: day, Hour: hour, Minute: minute, Second: second}
+	return t.Seconds() - int64(offset), nil
+}
+
+// fromExtJSON converts a tree decoded from Extended JSON by
+// encoding/json (nested map[string]interface{}, []interface{}, string,
+// float64, bool and nil values) into the native Go values this
+// package's Marshal already knows how to encode, recognizing the
+// $-prefixed type wrappers along the way.
+func fromExtJSON(v interface{}) (interface{}, os.Error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return fromExtJSONDoc(val)
+	case []interface{}:
+		s := make([]interface{}, len(val))
+		for i, e := range val {
+			cv, err := fromExtJSON(e)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = cv
+		}
+		return s, nil
+	}
+	return v, nil
+}
+
+func fromExtJSONDoc(m map[string]interface{}) (interface{}, os.Error) {
+	if code, ok := m["$code"].(string); ok {
+		if scope, ok := m["$scope"]; ok && len(m) == 2 {
+			cv, err := fromExtJSON(scope)
+			if err != nil {
+				return nil, err
+			}
+			scopeDoc, ok := cv.(map[string]interface{})
+			if !ok {
+				return nil, os.NewError("bson: $scope must be a document")
+			}
+			return &JavaScript{Code: code, Scope: scopeDoc}, nil
+		}
+		if len(m) == 1 {
+			return &JavaScript{Code: code}, nil
+		}
+	}
+	if len(m) == 1 {
+		for key, raw := range m {
+			switch key {
+			case "$oid":
+				s, ok := raw.(string)
+				if !ok {
+					return nil, os.NewError("bson: invalid $oid")
+				}
+				b, err := hex.DecodeString(s)
+				if err != nil || len(b) != 12 {
+					return nil, os.NewError("bson: invalid $oid " + s)
+				}
+				var o ObjectId
+				copy(o[:], b)
+				return &o, nil
+			case "$numberInt":
+				s, ok := raw.(string)
+				if !ok {
+					return nil, os.NewError("bson: invalid $numberInt")
+				}
+				n, err := strconv.Atoi(s)
+				if err != nil {
+					return nil, os.NewError("bson: invalid $numberInt " + s)
+				}
+				return int32(n), nil
+			case "$numberLong":
+				s, ok := raw.(string)
+				if !ok {
+					return nil, os.NewError("bson: invalid $numberLong")
+				}
+				n, err := strconv.Atoi64(s)
+				if err != nil {
+					return nil, os.NewError("bson: invalid $numberLong " + s)
+				}
+				return n, nil
+			case "$numberDouble":
+				s, ok := raw.(string)
+				if !ok {
+					return nil, os.NewError("bson: invalid $numberDouble")
+				}
+				switch s {
+				case "NaN":
+					return math.NaN(), nil
+				case "Infinity":
+					return math.Inf(1), nil
+				case "-Infinity":
+					return math.Inf(-1), nil
+				}
+				f, err := strconv.Atof64(s)
+				if err != nil {
+					return nil, os.NewError("bson: invalid $numberDouble " + s)
+				}
+				return f, nil
+			case "$numberDecimal":
+				s, ok := raw.(string)
+				if !ok {
+					return nil, os.NewError("bson: invalid $numberDecimal")
+				}
+				return ParseDecimal128(s)
+			case "$symbol":
+				s, ok := raw.(string)
+				if !ok {
+					return nil, os.NewError("bson: invalid $symbol")
+				}
+				return Symbol(s), nil
+			case "$maxKey":
+				return MaxKey{}, nil
+			case "$minKey":
+				return MinKey{}, nil
+			case "$date":
+				return fromExtJSONDate(raw)
+			case "$binary":
+				bm, ok := raw.(map[string]interface{})
+				if !ok {
+					return nil, os.NewError("bson: invalid $binary")
+				}
+				return fromExtJSONBinary(bm)
+			case "$regularExpression":
+				rm, ok := raw.(map[string]interface{})
+				if !ok {
+					return nil, os.NewError("bson: invalid $regularExpression")
+				}
+				return fromExtJSONRegexp(rm)
+			}
+		}
+	}
+
+	doc := make(map[string]interface{}, len(m))
+	for k, e := range m {
+		cv, err := fromExtJSON(e)
+		if err != nil {
+			return nil, err
+		}
+		doc[k] = cv
+	}
+	return doc, nil
+}
+
+func fromExtJSONDate(raw interface{}) (interface{}, os.Error) {
+	switch d := raw.(type) {
+	case string:
+		seconds, err := parseISO8601(d)
+		if err != nil {
+			return nil, err
+		}
+		return time.SecondsToUTC(seconds), nil
+	case map[string]interface{}:
+		s, ok := d["$numberLong"].(string)
+		if !ok {
+			return nil, os.NewError("bson: invalid $date")
+		}
+		millis, err := strconv.Atoi64(s)
+		if err != nil {
+			return nil, os.NewError("bson: invalid $date " + s)
+		}
+		return time.SecondsToUTC(millis / 1000), nil
+	}
+	return nil, os.NewError("bson: invalid $date")
+}
+
+func fromExtJSONBinary(m map[string]interface{}) (interface{}, os.Error) {
+	b64, ok := m["base64"].(string)
+	if !ok {
+		return nil, os.NewError("bson: invalid $binary")
+	}
+	subTypeStr, ok := m["subType"].(string)
+	if !ok {
+		return nil, os.NewError("bson: invalid $binary")
+	}
+	data, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return nil, os.NewError("bson: invalid $binary base64")
+	}
+	subType, err := strconv.Btoui64(subTypeStr, 16)
+	if err != nil {
+		return nil, os.NewError("bson: invalid $binary subType " + subTypeStr)
+	}
+	return Binary{byte(subType), data}, nil
+}
+
+func fromExtJSONRegexp(m map[string]interface{}) (interface{}, os.Error) {
+	pattern, _ := m["pattern"].(string)
+	options, _ := m["options"].(string)
+	return &Regexp{Expr: pattern, Options: options}, nil
+}
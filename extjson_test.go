@@ -0,0 +1,91 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMarshalExtJSONCanonical(t *testing.T) {
+	doc := map[string]interface{}{
+		"i":  int32(1),
+		"l":  int64(2),
+		"s":  "hi",
+		"id": &ObjectId{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c},
+	}
+	b, err := MarshalExtJSON(doc, true)
+	if err != nil {
+		t.Fatalf("MarshalExtJSON error: %s", err.String())
+	}
+	s := string(b)
+	for _, want := range []string{`"$numberInt":"1"`, `"$numberLong":"2"`, `"$oid":"0102030405060708090a0b0c"`} {
+		if !strings.Contains(s, want) {
+			t.Errorf("MarshalExtJSON(canonical) = %s, missing %s", s, want)
+		}
+	}
+}
+
+func TestMarshalExtJSONRelaxed(t *testing.T) {
+	b, err := MarshalExtJSON(map[string]interface{}{"i": int32(1)}, false)
+	if err != nil {
+		t.Fatalf("MarshalExtJSON error: %s", err.String())
+	}
+	if strings.Contains(string(b), "$numberInt") {
+		t.Errorf("MarshalExtJSON(relaxed) = %s, want a plain JSON number", b)
+	}
+}
+
+func TestExtJSONRoundTrip(t *testing.T) {
+	orig := map[string]interface{}{
+		"i": int32(42),
+		"l": int64(1 << 40),
+		"s": "hello",
+		"b": true,
+		"d": Decimal128{H: 0, L: 0},
+		"r": &Regexp{Expr: "^a+$", Options: "i"},
+		"a": []interface{}{int32(1), int32(2), int32(3)},
+	}
+	b, err := MarshalExtJSON(orig, true)
+	if err != nil {
+		t.Fatalf("MarshalExtJSON error: %s", err.String())
+	}
+
+	var got map[string]interface{}
+	if err := UnmarshalExtJSON(b, &got); err != nil {
+		t.Fatalf("UnmarshalExtJSON error: %s", err.String())
+	}
+
+	if got["i"] != int32(42) {
+		t.Errorf("got[\"i\"] = %v, want 42", got["i"])
+	}
+	if got["l"] != int64(1<<40) {
+		t.Errorf("got[\"l\"] = %v, want %d", got["l"], int64(1<<40))
+	}
+	if got["s"] != "hello" {
+		t.Errorf("got[\"s\"] = %v, want \"hello\"", got["s"])
+	}
+	a, ok := got["a"].([]interface{})
+	if !ok || len(a) != 3 {
+		t.Errorf("got[\"a\"] = %v, want a 3-element slice", got["a"])
+	}
+}
+
+func TestUnmarshalExtJSONLegacyDate(t *testing.T) {
+	var doc struct {
+		D *time.Time
+	}
+	data := []byte(`{"d":{"$date":"2020-01-02T03:04:05Z"}}`)
+	if err := UnmarshalExtJSON(data, &doc); err != nil {
+		t.Fatalf("UnmarshalExtJSON error: %s", err.String())
+	}
+	if doc.D == nil {
+		t.Fatal("doc.D = nil, want a decoded time")
+	}
+	if doc.D.Year != 2020 || doc.D.Month != 1 || doc.D.Day != 2 {
+		t.Errorf("doc.D = %+v, want 2020-01-02", doc.D)
+	}
+}
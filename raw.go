@@ -0,0 +1,109 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"os"
+	"reflect"
+)
+
+// Raw holds a single BSON element's kind byte and its undecoded Data.
+// A struct or map field typed as Raw is left untouched by Unmarshal
+// instead of being decoded eagerly, so callers that only need a couple
+// of fields out of a large document don't pay to decode the rest of it.
+type Raw struct {
+	Kind byte
+	Data []byte
+}
+
+// RawDocument holds the complete bytes of a BSON document, including its
+// leading 4-byte length prefix, exactly as they appear on the wire.
+type RawDocument []byte
+
+var rawType = reflect.Typeof(Raw{})
+var rawDocumentType = reflect.Typeof(RawDocument(nil))
+
+// MarshalBSON implements Marshaler by writing back the exact bytes r was
+// decoded from. Writers of BSON elements (writeKeyVal's Marshaler path
+// among them) write the returned bytes verbatim after the kind and key,
+// so for a length-prefixed kind (document, array, string, and the
+// like) r.Data, which Unmarshal stored without its length prefix, must
+// have that prefix restored first.
+func (r Raw) MarshalBSON() (byte, []byte, os.Error) {
+	return r.Kind, prependLength(r.Kind, r.Data), nil
+}
+
+// MarshalBSON implements Marshaler by writing back the exact bytes d was
+// decoded from, including their leading 4-byte length prefix: unlike
+// Raw, RawDocument already carries it.
+func (d RawDocument) MarshalBSON() (byte, []byte, os.Error) {
+	if len(d) < 4 {
+		return elDoc, nil, os.NewError("bson: invalid RawDocument")
+	}
+	return elDoc, d, nil
+}
+
+// prependLength restores the 4-byte length prefix readChunk strips off
+// a length-prefixed element's data before handing it back, so the
+// result is valid wire bytes again. Kinds readChunk doesn't treat as
+// length-prefixed are returned unchanged.
+func prependLength(kind byte, data []byte) []byte {
+	var n int32
+	switch kind {
+	case elString, elJavaScript, elSymbol:
+		n = int32(len(data))
+	case elDoc, elArray, elJavaScope:
+		n = int32(len(data) + 4)
+	case elBinary:
+		n = int32(len(data) - 1)
+	default:
+		return data
+	}
+	b := make([]byte, 4+len(data))
+	order.PutUint32(b, uint32(n))
+	copy(b[4:], data)
+	return b
+}
+
+// Unmarshal decodes r into v, which should be a pointer as with the
+// top-level Unmarshal function.
+func (r Raw) Unmarshal(v interface{}) (err os.Error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			switch rval := rec.(type) {
+			case os.Error:
+				err = rval
+			case string:
+				err = os.NewError(rval)
+			default:
+				panic(rec)
+			}
+		}
+	}()
+	d := &decodeState{b: r.Data}
+	d.decodeElem(r.Kind, r.Data, indirect(reflect.NewValue(v)))
+	return
+}
+
+// Lookup walks the document held by r, which must have Kind elDoc, and
+// returns the Raw element named key without decoding any of the other
+// elements or allocating an intermediate map. It returns the zero Raw
+// if r isn't a document or doesn't contain key.
+func (r Raw) Lookup(key string) Raw {
+	if r.Kind != elDoc {
+		return Raw{}
+	}
+	d := &decodeState{b: r.Data}
+	for {
+		kind, k, b := d.readChunk()
+		if kind == 0 {
+			return Raw{}
+		}
+		if k == key {
+			return Raw{kind, b}
+		}
+	}
+	panic("unreachable")
+}
@@ -0,0 +1,66 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"testing"
+)
+
+func TestRawStructField(t *testing.T) {
+	b, err := Marshal(map[string]interface{}{"inner": map[string]interface{}{"a": int32(1)}})
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err.String())
+	}
+
+	var doc struct {
+		Inner Raw
+	}
+	if err := Unmarshal(b, &doc); err != nil {
+		t.Fatalf("Unmarshal error: %s", err.String())
+	}
+	if doc.Inner.Kind != elDoc {
+		t.Fatalf("Inner.Kind = %#x, want %#x", doc.Inner.Kind, elDoc)
+	}
+
+	var inner map[string]interface{}
+	if err := doc.Inner.Unmarshal(&inner); err != nil {
+		t.Fatalf("Raw.Unmarshal error: %s", err.String())
+	}
+	if inner["a"] != int32(1) {
+		t.Errorf("inner[\"a\"] = %v, want 1", inner["a"])
+	}
+}
+
+func TestRawLookup(t *testing.T) {
+	b, err := Marshal(map[string]interface{}{"a": int32(1), "b": "two"})
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err.String())
+	}
+
+	outer, err := Marshal(map[string]interface{}{"doc": RawDocument(b)})
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err.String())
+	}
+
+	var doc struct {
+		Doc Raw
+	}
+	if err := Unmarshal(outer, &doc); err != nil {
+		t.Fatalf("Unmarshal error: %s", err.String())
+	}
+
+	b2 := doc.Doc.Lookup("b")
+	var s string
+	if err := b2.Unmarshal(&s); err != nil {
+		t.Fatalf("Raw.Unmarshal error: %s", err.String())
+	}
+	if s != "two" {
+		t.Errorf("Lookup(\"b\") = %q, want \"two\"", s)
+	}
+
+	if missing := doc.Doc.Lookup("missing"); missing.Kind != 0 {
+		t.Errorf("Lookup(\"missing\").Kind = %#x, want 0", missing.Kind)
+	}
+}
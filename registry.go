@@ -0,0 +1,58 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"os"
+	"reflect"
+)
+
+// Setter is implemented by types that want to decode themselves from a
+// raw BSON element, the decoding counterpart to Marshaler.
+type Setter interface {
+	SetBSON(raw Raw) os.Error
+}
+
+// Getter is implemented by types that want to substitute a different
+// value to be encoded in their place, the encoding counterpart to
+// Setter. Unlike Marshaler, GetBSON doesn't have to produce wire bytes
+// itself; it just returns a value for Marshal to encode as usual.
+type Getter interface {
+	GetBSON() (interface{}, os.Error)
+}
+
+// Unmarshaler is implemented by types that want to decode themselves
+// from raw wire bytes, the decoding counterpart to Marshaler. Unlike
+// Setter, UnmarshalBSON receives the kind byte and element bytes
+// directly instead of a Raw, with any length prefix restored so that
+// they are the same bytes MarshalBSON returns, matching the signature
+// cmd/bsongen emits for generated types.
+type Unmarshaler interface {
+	UnmarshalBSON(kind byte, data []byte) os.Error
+}
+
+// codec holds a pair of functions used to encode and decode values of a
+// single registered type.
+type codec struct {
+	encode func(v interface{}) (byte, []byte, os.Error)
+	decode func(kind byte, b []byte, val reflect.Value) os.Error
+}
+
+var registry = make(map[reflect.Type]codec)
+
+// RegisterCodec registers encode and decode functions for values of
+// type t, so that Marshal and Unmarshal use them instead of the
+// package's built-in type switch whenever they encounter a value of
+// that type. Registering a codec for a type that already has one
+// replaces the previous codec.
+func RegisterCodec(t reflect.Type, encode func(v interface{}) (byte, []byte, os.Error), decode func(kind byte, b []byte, val reflect.Value) os.Error) {
+	registry[t] = codec{encode, decode}
+}
+
+// lookupCodec returns the codec registered for t, if any.
+func lookupCodec(t reflect.Type) (codec, bool) {
+	c, ok := registry[t]
+	return c, ok
+}
@@ -0,0 +1,63 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type upperString string
+
+func (s upperString) GetBSON() (interface{}, os.Error) {
+	return strings.ToUpper(string(s)), nil
+}
+
+func TestGetterEncodesSubstituteValue(t *testing.T) {
+	b, err := Marshal(map[string]interface{}{"s": upperString("hi")})
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err.String())
+	}
+
+	var doc map[string]interface{}
+	if err := Unmarshal(b, &doc); err != nil {
+		t.Fatalf("Unmarshal error: %s", err.String())
+	}
+	if doc["s"] != "HI" {
+		t.Errorf("doc[\"s\"] = %v, want \"HI\"", doc["s"])
+	}
+}
+
+type halved int32
+
+func TestRegisterCodecRoundTrip(t *testing.T) {
+	RegisterCodec(reflect.Typeof(halved(0)),
+		func(v interface{}) (byte, []byte, os.Error) {
+			b := make([]byte, 4)
+			order.PutUint32(b, uint32(v.(halved))*2)
+			return elInt32, b, nil
+		},
+		func(kind byte, b []byte, val reflect.Value) os.Error {
+			val.(*reflect.IntValue).Set(int64(order.Uint32(b) / 2))
+			return nil
+		})
+
+	b, err := Marshal(map[string]interface{}{"n": halved(21)})
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err.String())
+	}
+
+	var doc struct {
+		N halved
+	}
+	if err := Unmarshal(b, &doc); err != nil {
+		t.Fatalf("Unmarshal error: %s", err.String())
+	}
+	if doc.N != 21 {
+		t.Errorf("doc.N = %d, want 21", doc.N)
+	}
+}
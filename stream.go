@@ -0,0 +1,122 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// DefaultMaxDocumentSize is the largest document a Decoder will read
+// unless overridden by setting Decoder.MaxDocumentSize, matching
+// MongoDB's own document size limit. It guards against a corrupt or
+// malicious length prefix causing an enormous allocation.
+const DefaultMaxDocumentSize = 16 * 1024 * 1024
+
+// An Encoder writes a stream of BSON documents to an output stream, each
+// framed exactly as it appears on the wire, so callers can produce
+// concatenated .bson files or wire-protocol replies without buffering
+// the whole stream into memory first.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns a new Encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w}
+}
+
+// Encode writes the BSON encoding of v to the stream.
+func (enc *Encoder) Encode(v interface{}) os.Error {
+	b, err := Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = enc.w.Write(b)
+	return err
+}
+
+// A Decoder reads a stream of length-prefixed BSON documents, such as a
+// mongodump .bson file or an oplog tail, from an input stream.
+type Decoder struct {
+	r   io.Reader
+	buf []byte
+	// MaxDocumentSize limits the size of a single document Decode will
+	// read. It defaults to DefaultMaxDocumentSize; callers expecting
+	// larger documents can raise it after calling NewDecoder.
+	MaxDocumentSize int
+}
+
+// NewDecoder returns a new Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r, MaxDocumentSize: DefaultMaxDocumentSize}
+}
+
+// Buffered returns a reader of the bytes already read from the
+// underlying io.Reader but not yet consumed by Decode, analogous to
+// bufio.Reader's Buffered method.
+func (dec *Decoder) Buffered() io.Reader {
+	return bytes.NewBuffer(dec.buf)
+}
+
+// More reports whether there is at least one more byte available to
+// read, i.e. whether a subsequent call to Decode has a document to
+// decode rather than returning os.EOF.
+func (dec *Decoder) More() bool {
+	if len(dec.buf) > 0 {
+		return true
+	}
+	var b [1]byte
+	n, _ := dec.r.Read(b[:])
+	if n > 0 {
+		dec.buf = append(dec.buf, b[:n]...)
+	}
+	return len(dec.buf) > 0
+}
+
+// Decode reads the next length-prefixed BSON document from the stream
+// and stores the result in v.
+func (dec *Decoder) Decode(v interface{}) os.Error {
+	lenBuf, err := dec.readFull(4)
+	if err != nil {
+		return err
+	}
+	l := int(order.Uint32(lenBuf))
+	if l < 4 {
+		return os.NewError("bson: invalid document length")
+	}
+	max := dec.MaxDocumentSize
+	if max == 0 {
+		max = DefaultMaxDocumentSize
+	}
+	if l > max {
+		return os.NewError("bson: document exceeds MaxDocumentSize")
+	}
+	rest, err := dec.readFull(l - 4)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(append(lenBuf, rest...), v)
+}
+
+// readFull returns the next n bytes from the stream, first draining any
+// bytes buffered by a previous call to More.
+func (dec *Decoder) readFull(n int) ([]byte, os.Error) {
+	b := make([]byte, n)
+	have := copy(b, dec.buf)
+	dec.buf = dec.buf[have:]
+	for have < n {
+		m, err := dec.r.Read(b[have:])
+		have += m
+		if err != nil {
+			if have == n {
+				break
+			}
+			return nil, err
+		}
+	}
+	return b, nil
+}
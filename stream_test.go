@@ -0,0 +1,114 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// eofWithDataReader hands back as many of its remaining bytes as fit in
+// each read, tagging every read -- including ones that leave data still
+// unconsumed -- with os.EOF, which io.Reader permits a reader to do on
+// the read that delivers the last of its data.
+type eofWithDataReader struct {
+	data []byte
+}
+
+func (r *eofWithDataReader) Read(p []byte) (int, os.Error) {
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, os.EOF
+}
+
+func TestEncoderDecoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	docs := []map[string]interface{}{
+		{"a": int32(1)},
+		{"b": "hello"},
+	}
+	for _, doc := range docs {
+		if err := enc.Encode(doc); err != nil {
+			t.Fatalf("Encode error: %s", err.String())
+		}
+	}
+
+	dec := NewDecoder(&buf)
+	for i, want := range docs {
+		if !dec.More() {
+			t.Fatalf("#%d: More() = false, want true", i)
+		}
+		got := map[string]interface{}{}
+		if err := dec.Decode(&got); err != nil {
+			t.Fatalf("#%d: Decode error: %s", i, err.String())
+		}
+		if got["a"] != want["a"] || got["b"] != want["b"] {
+			t.Errorf("#%d: got %+v, want %+v", i, got, want)
+		}
+	}
+	if dec.More() {
+		t.Error("More() = true at end of stream, want false")
+	}
+}
+
+func TestDecoderMaxDocumentSize(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.Encode(map[string]interface{}{"s": "hello, world"}); err != nil {
+		t.Fatalf("Encode error: %s", err.String())
+	}
+
+	dec := NewDecoder(&buf)
+	dec.MaxDocumentSize = 8
+	var got map[string]interface{}
+	if err := dec.Decode(&got); err == nil {
+		t.Fatal("Decode with a too-small MaxDocumentSize succeeded, want error")
+	}
+}
+
+func TestDecoderBuffered(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	if err := enc.Encode(map[string]interface{}{"a": int32(1)}); err != nil {
+		t.Fatalf("Encode error: %s", err.String())
+	}
+	if err := enc.Encode(map[string]interface{}{"b": int32(2)}); err != nil {
+		t.Fatalf("Encode error: %s", err.String())
+	}
+
+	dec := NewDecoder(&buf)
+	if !dec.More() {
+		t.Fatal("More() = false, want true")
+	}
+	b, err := ioutil.ReadAll(dec.Buffered())
+	if err != nil {
+		t.Fatalf("ReadAll error: %s", err)
+	}
+	if len(b) != 1 {
+		t.Fatalf("Buffered() returned %d bytes, want 1", len(b))
+	}
+}
+
+func TestDecoderMoreWithDataAndEOF(t *testing.T) {
+	b, err := Marshal(map[string]interface{}{"a": int32(1)})
+	if err != nil {
+		t.Fatalf("Marshal error: %s", err.String())
+	}
+
+	dec := NewDecoder(&eofWithDataReader{b})
+	if !dec.More() {
+		t.Fatal("More() = false, want true for a reader that pairs its last byte with EOF")
+	}
+	var got map[string]interface{}
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("Decode error: %s", err.String())
+	}
+	if got["a"] != int32(1) {
+		t.Errorf("got %+v, want a=1", got)
+	}
+}
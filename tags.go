@@ -0,0 +1,100 @@
+// Copyright 2010, Evan Shaw. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bson
+
+import (
+	"reflect"
+	"strings"
+)
+
+// tagOptions describes a struct field's bson tag: "name,opt1,opt2", in
+// the style mgo and globalsign/mgo use. A bare tag with no commas is
+// just the name, which keeps the previous behavior of this package.
+type tagOptions struct {
+	name      string
+	skip      bool
+	omitempty bool
+	inline    bool
+	minsize   bool
+}
+
+func parseTag(tag string) tagOptions {
+	if tag == "-" {
+		return tagOptions{skip: true}
+	}
+	parts := strings.Split(tag, ",")
+	opts := tagOptions{name: parts[0]}
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "omitempty":
+			opts.omitempty = true
+		case "inline":
+			opts.inline = true
+		case "minsize":
+			opts.minsize = true
+		}
+	}
+	return opts
+}
+
+// fieldTag returns the parsed tag for a struct field, defaulting the name
+// to the field's Go name when no tag was given.
+func fieldTag(field reflect.StructField) tagOptions {
+	opts := parseTag(lookupBSONTag(field.Tag))
+	if opts.name == "" {
+		opts.name = field.Name
+	}
+	return opts
+}
+
+// lookupBSONTag extracts the value of a `bson:"..."` key from a struct
+// tag written in the conventional `key:"value" key2:"value2"` form. If
+// tag doesn't look like that form at all (no "name:"value"" pair is
+// found), it's returned unchanged so that the older bare "name,opt1,opt2"
+// tags already used throughout this package keep working.
+func lookupBSONTag(tag string) string {
+	for tag != "" {
+		i := strings.IndexByte(tag, ':')
+		if i <= 0 || i+1 >= len(tag) || tag[i+1] != '"' {
+			break
+		}
+		name := strings.TrimSpace(tag[:i])
+		rest := tag[i+2:]
+		j := strings.IndexByte(rest, '"')
+		if j < 0 {
+			break
+		}
+		value := rest[:j]
+		if name == "bson" {
+			return value
+		}
+		tag = strings.TrimSpace(rest[j+1:])
+	}
+	return tag
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v := v.(type) {
+	case *reflect.StringValue:
+		return v.Get() == ""
+	case *reflect.BoolValue:
+		return !v.Get()
+	case *reflect.IntValue:
+		return v.Get() == 0
+	case *reflect.UintValue:
+		return v.Get() == 0
+	case *reflect.FloatValue:
+		return v.Get() == 0
+	case reflect.ArrayOrSliceValue:
+		return v.Len() == 0
+	case *reflect.MapValue:
+		return v.Len() == 0
+	case *reflect.PtrValue:
+		return v.IsNil()
+	case *reflect.InterfaceValue:
+		return v.IsNil()
+	}
+	return false
+}